@@ -0,0 +1,8 @@
+package api
+
+import "net/http"
+
+// RegisterRoutes 把本包提供的接口挂到 mux 上，供启动 HTTP 服务的地方调用
+func RegisterRoutes(mux *http.ServeMux, indexFile string) {
+	mux.HandleFunc("/api/tree", TreeHandler(indexFile))
+}
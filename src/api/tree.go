@@ -0,0 +1,29 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"dxkite.cn/explore-me/src/core"
+)
+
+// TreeHandler 处理 GET /api/tree：读取 index.json，折叠成目录树后以
+// zstd 压缩整体下发，客户端因此可以一次拿到完整层级结构做离线浏览
+func TreeHandler(indexFile string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		indices, err := core.LoadAllIndices(indexFile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tree := core.BuildTree(indices)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "zstd")
+
+		if err := core.WriteTreeZstd(w, tree); err != nil {
+			log.Println("write tree zstd error:", err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+
+	"dxkite.cn/explore-me/src/core"
+)
+
+// Dispatch 按子命令名把 args[0] 之后的参数路由给对应的 Run* 实现，
+// 是 explorer 子命令集合实际对外暴露的入口
+func Dispatch(cfg *core.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand")
+	}
+
+	switch args[0] {
+	case "reindex":
+		return RunReindex(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand: %s", args[0])
+	}
+}
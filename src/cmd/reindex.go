@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"dxkite.cn/explore-me/src/core"
+)
+
+// RunReindex 实现 `explorer reindex --only=ext,tag,inverted` 子命令：
+// 流式读取已有的 index.json，只重建请求的辅助产物，不重新扫描文件系统。
+// 在改过 TagExpr 或调整过提取器注册表之后，用它刷新标签相关的产物会比
+// 对着 TB 级的媒体库重新走一遍全量扫描快得多。
+func RunReindex(cfg *core.Config, args []string) error {
+	set := flag.NewFlagSet("reindex", flag.ExitOnError)
+	only := set.String("only", "ext,tag,inverted", "comma-separated list of artifacts to rebuild: ext,tag,inverted")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	targets := map[string]bool{}
+	for _, name := range strings.Split(*only, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			targets[name] = true
+		}
+	}
+
+	ic := core.NewIndexCreator(&cfg.ScanConfig)
+	if err := ic.RebuildAux(cfg.SrcRoot, cfg.DataRoot, targets); err != nil {
+		return fmt.Errorf("reindex: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package dto
+
+// MatchLevel 描述一次查询在某个字段上的命中程度
+type MatchLevel string
+
+const (
+	MatchLevelNone    MatchLevel = "none"
+	MatchLevelPartial MatchLevel = "partial"
+	MatchLevelFull    MatchLevel = "full"
+)
+
+// Match 是某个字段的查询命中结果，用于前端直接渲染结果片段，
+// 无需在客户端重新执行一次匹配
+type Match struct {
+	Value      string     `json:"value"`
+	MatchLevel MatchLevel `json:"matchLevel"`
+	Matches    []string   `json:"matches,omitempty"`
+	Highlight  string     `json:"highlight,omitempty"`
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Posting 记录一个词条在 index.json 中的出现位置
+type Posting struct {
+	Offset    int64 `json:"offset"`
+	Positions []int `json:"positions"`
+}
+
+// InvertedIndex 是 index.json 的倒排索引，随 index.json 一起生成，
+// 用于让 SearchFile 直接定位命中记录而不必逐条扫描
+type InvertedIndex struct {
+	Tokens   map[string][]Posting `json:"tokens"`
+	TagExact map[string][]int64   `json:"tag_exact"`
+	ExtExact map[string][]int64   `json:"ext_exact"`
+	Trigrams map[string][]int64   `json:"trigrams"`
+}
+
+func newInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		Tokens:   map[string][]Posting{},
+		TagExact: map[string][]int64{},
+		ExtExact: map[string][]int64{},
+		Trigrams: map[string][]int64{},
+	}
+}
+
+var tokenSplitExpr = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// tokenize 按分隔符切分并做小写折叠
+func tokenize(s string) []string {
+	s = strings.ToLower(s)
+	parts := tokenSplitExpr.Split(s, -1)
+	tokens := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			tokens = append(tokens, p)
+		}
+	}
+	return tokens
+}
+
+// trigrams 生成字符串的 trigram 集合，用于子串查询
+func trigrams(s string) []string {
+	r := []rune(strings.ToLower(s))
+	if len(r) == 0 {
+		return nil
+	}
+	if len(r) < 3 {
+		return []string{string(r)}
+	}
+	grams := make([]string, 0, len(r)-2)
+	for i := 0; i+3 <= len(r); i++ {
+		grams = append(grams, string(r[i:i+3]))
+	}
+	return grams
+}
+
+// add 将一条记录并入倒排索引，offset 是该记录在 index.json 中的起始偏移
+func (idx *InvertedIndex) add(offset int64, name, filePath, ext string, tags []string) {
+	for _, field := range []string{name, filePath} {
+		for pos, tok := range tokenize(field) {
+			idx.Tokens[tok] = append(idx.Tokens[tok], Posting{Offset: offset, Positions: []int{pos}})
+		}
+	}
+
+	idx.ExtExact[ext] = appendUniqueOffset(idx.ExtExact[ext], offset)
+	for _, t := range tags {
+		idx.TagExact[t] = appendUniqueOffset(idx.TagExact[t], offset)
+	}
+
+	for _, g := range trigrams(name + "/" + filePath) {
+		idx.Trigrams[g] = appendUniqueOffset(idx.Trigrams[g], offset)
+	}
+}
+
+func appendUniqueOffset(list []int64, v int64) []int64 {
+	if len(list) > 0 && list[len(list)-1] == v {
+		return list
+	}
+	return append(list, v)
+}
+
+func loadInvertedIndex(filename string) (*InvertedIndex, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	idx := newInvertedIndex()
+	if err := json.Unmarshal(b, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// lookup 尝试用 match 命中倒排索引，ok=false 表示查询为空（没有可用的词条），
+// 调用方应当回退到全量扫描
+func (idx *InvertedIndex) lookup(match SearchParams) (map[int64]bool, bool) {
+	used := false
+	var sets []map[int64]bool
+
+	if match.Ext != "" {
+		sets = append(sets, toOffsetSet(idx.ExtExact[match.Ext]))
+		used = true
+	}
+	if match.Tag != "" {
+		sets = append(sets, toOffsetSet(idx.TagExact[match.Tag]))
+		used = true
+	}
+	if match.Name != "" {
+		if s, ok := idx.substringSet(match.Name); ok {
+			sets = append(sets, s)
+			used = true
+		}
+	}
+	if match.Path != "" {
+		if s, ok := idx.substringSet(match.Path); ok {
+			sets = append(sets, s)
+			used = true
+		}
+	}
+
+	if !used {
+		return nil, false
+	}
+	return intersectOffsetSets(sets), true
+}
+
+// substringSet 总是通过 trigram 索引做子串查询的候选反查。不能用 Tokens
+// 做整词精确匹配代替——查询串往往只是某个词的一部分（例如 "pisode" 是
+// "episode01" 的子串，但不是一个完整分词，在 Tokens 里找不到）。
+// trigram 交集只给出候选集，可能有假阳性，由调用方的强匹配校验兜底过滤。
+//
+// 查询长度小于 3 个字符时直接返回 ok=false 交给调用方回退到线性扫描：
+// add 只在索引时为 name+"/"+filePath（长度几乎总是 >=3）生成真正的 3 字符
+// 窗口，trigrams(q) 对短查询返回的是查询串本身而不是窗口，永远不会命中
+// idx.Trigrams，会把 ok=true 和一个恒为空的候选集一起返回，丢光短查询的结果。
+func (idx *InvertedIndex) substringSet(q string) (map[int64]bool, bool) {
+	if len([]rune(q)) < 3 {
+		return nil, false
+	}
+
+	grams := trigrams(q)
+	if len(grams) == 0 {
+		return nil, false
+	}
+	sets := make([]map[int64]bool, 0, len(grams))
+	for _, g := range grams {
+		sets = append(sets, toOffsetSet(idx.Trigrams[g]))
+	}
+	return intersectOffsetSets(sets), true
+}
+
+func toOffsetSet(list []int64) map[int64]bool {
+	s := make(map[int64]bool, len(list))
+	for _, v := range list {
+		s[v] = true
+	}
+	return s
+}
+
+func intersectOffsetSets(sets []map[int64]bool) map[int64]bool {
+	if len(sets) == 0 {
+		return map[int64]bool{}
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	rst := map[int64]bool{}
+	for off := range sets[0] {
+		hit := true
+		for _, s := range sets[1:] {
+			if !s[off] {
+				hit = false
+				break
+			}
+		}
+		if hit {
+			rst[off] = true
+		}
+	}
+	return rst
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -10,6 +11,9 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"dxkite.cn/explore-me/src/core/scan"
+	"dxkite.cn/explore-me/src/core/stream"
 )
 
 type FileInfo struct {
@@ -113,7 +117,12 @@ func (ic *IndexCreator) createIndexFile(root, dataRoot string) error {
 
 	absRootPath, _ := filepath.Abs(root)
 
-	return filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+	extractors := buildTagExtractorRegistry(ic.Config, reg)
+
+	invIdx := newInvertedIndex()
+	var offset int64
+
+	walkErr := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
@@ -143,6 +152,10 @@ func (ic *IndexCreator) createIndexFile(root, dataRoot string) error {
 			return err
 		}
 
+		if extra, err := extractors.extract(path, info); err == nil {
+			tags = mergeTags(tags, extra)
+		}
+
 		for _, v := range tags {
 			ic.tagMap[v] = true
 		}
@@ -159,15 +172,27 @@ func (ic *IndexCreator) createIndexFile(root, dataRoot string) error {
 		if b, err := json.Marshal(v); err != nil {
 			return err
 		} else {
-			if _, err := idx.Write(b); err != nil {
+			n, err := idx.Write(b)
+			if err != nil {
 				return err
 			}
-			if _, err := idx.Write([]byte{'\n'}); err != nil {
+			invIdx.add(offset, v.Name, v.Path, v.Ext, v.Tags)
+			offset += int64(n)
+
+			if n, err := idx.Write([]byte{'\n'}); err != nil {
 				return err
+			} else {
+				offset += int64(n)
 			}
 		}
 		return nil
 	})
+
+	if walkErr != nil {
+		return walkErr
+	}
+
+	return writeJsonFile(index+".inv", invIdx)
 }
 
 func (ic *IndexCreator) getMeta(dataRoot string) *MetaData {
@@ -204,6 +229,147 @@ func (ic *IndexCreator) createTagListFile(dataRoot string) error {
 	return nil
 }
 
+// RebuildAux 流式读取已有的 index.json，只重建 only 中请求的辅助产物
+// （"ext"、"tag"、"inverted"），不重新扫描文件系统。当 only 包含 "tag" 或
+// "inverted" 时，会按当前的 TagExpr/提取器注册表对每条记录重新推导标签
+// 并把结果写回 index.json——否则聚合出来的标签列表仍然来自全量扫描时的
+// 旧正则/旧提取器，改了 TagExpr 之后 reindex 也刷新不出新标签。
+func (ic *IndexCreator) RebuildAux(root, dataRoot string, only map[string]bool) error {
+	reg, err := regexp.Compile(ic.Config.TagExpr)
+	if err != nil {
+		return err
+	}
+	extractors := buildTagExtractorRegistry(ic.Config, reg)
+
+	refreshTags := only["tag"] || only["inverted"]
+
+	indexFile := path.Join(dataRoot, ic.Config.IndexFile)
+
+	in, err := os.OpenFile(indexFile, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	s := stream.NewJsonStream(in)
+
+	extMap := map[string]bool{}
+	tagMap := map[string]bool{}
+	invIdx := newInvertedIndex()
+
+	var out *os.File
+	tmpFile := indexFile + ".tmp"
+	if refreshTags {
+		out, err = os.OpenFile(tmpFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	var offset int64
+
+	for {
+		_, info, err := s.ScanNext(&scan.Index{})
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		fi := info.(*scan.Index)
+
+		if refreshTags {
+			fi.Tags = ic.deriveTags(root, fi, reg, extractors)
+		}
+
+		if only["ext"] {
+			extMap[fi.Ext] = false
+		}
+		if only["tag"] {
+			for _, t := range fi.Tags {
+				tagMap[t] = true
+			}
+		}
+
+		if out != nil {
+			b, err := json.Marshal(fi)
+			if err != nil {
+				return err
+			}
+			n, err := out.Write(b)
+			if err != nil {
+				return err
+			}
+			if only["inverted"] {
+				invIdx.add(offset, fi.Name, fi.Path, fi.Ext, fi.Tags)
+			}
+			offset += int64(n)
+
+			n, err = out.Write([]byte{'\n'})
+			if err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+	}
+
+	if out != nil {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		if err := in.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpFile, indexFile); err != nil {
+			return err
+		}
+	}
+
+	if only["ext"] {
+		if err := writeJsonFile(path.Join(dataRoot, ic.Config.ExtListFile), extMap); err != nil {
+			return err
+		}
+	}
+
+	if only["tag"] {
+		tags := []string{}
+		for k := range tagMap {
+			tags = append(tags, k)
+		}
+		if err := writeJsonFile(path.Join(dataRoot, ic.Config.TagListFile), tags); err != nil {
+			return err
+		}
+	}
+
+	if only["inverted"] {
+		if err := writeJsonFile(indexFile+".inv", invIdx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deriveTags 用当前的 TagExpr 与提取器注册表重新计算一条记录的标签，
+// 需要访问原始文件时按 root+fi.Path 去读（文件若已不存在则只保留文件名标签）
+func (ic *IndexCreator) deriveTags(root string, fi *scan.Index, reg *regexp.Regexp, extractors *tagExtractorRegistry) []string {
+	tags, err := parseTag(fi.Name, reg)
+	if err != nil {
+		return fi.Tags
+	}
+
+	filePath := path.Join(root, fi.Path)
+	if info, err := os.Stat(filePath); err == nil {
+		if extra, err := extractors.extract(filePath, info); err == nil {
+			tags = mergeTags(tags, extra)
+		}
+	}
+
+	return tags
+}
+
 func writeJsonFile(filename string, v interface{}) error {
 	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
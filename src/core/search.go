@@ -1,10 +1,14 @@
 package core
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
+	"dxkite.cn/explore-me/src/core/dto"
 	"dxkite.cn/explore-me/src/core/scan"
 	"dxkite.cn/explore-me/src/core/stream"
 )
@@ -16,12 +20,118 @@ type SearchParams struct {
 	Path string
 }
 
+// SearchFileInfo 里的 Id 是这条记录在当前数据源下的稳定标识：
+// 文件路径（searchFileLinear/searchByOffsets）下是它在 index.json 里的字节
+// 偏移；IndexWatcher 内存路径（searchInMemory）下是 WatcherEntry 分配的
+// 稳定 id（flush 压缩不会改变它；重命名只有在同一个 flush 周期内、新旧路径
+// 文件名相同时才保留原 id，否则新路径会分到新的 id）。两种 Id 的取值空间互不相关，
+// 不能跨数据源持久化比较，但在各自的来源内都足够稳定，可以当作去重/分页游标用。
 type SearchFileInfo struct {
 	Id int64 `json:"id"`
 	*scan.Index
+	Highlights map[string]dto.Match `json:"highlights,omitempty"`
 }
 
+// HighlightOpenTag、HighlightCloseTag 包裹命中片段，外部可以替换成自己的标签
+var (
+	HighlightOpenTag  = "<em>"
+	HighlightCloseTag = "</em>"
+)
+
+// SearchFile 查询 index.json。若有 IndexWatcher 在运行，直接用它的内存缓存
+// 做匹配，完全跳过文件 I/O；否则优先使用同目录下的倒排索引 (index.json.inv)
+// 直接定位命中记录；索引不存在或查询为空时回退到全量扫描
 func SearchFile(filename string, match SearchParams, offset, limit int64) ([]*SearchFileInfo, error) {
+	if w := getActiveWatcher(); w != nil {
+		return searchInMemory(w.Snapshot(), match, offset, limit), nil
+	}
+
+	if invIdx, err := loadInvertedIndex(filename + ".inv"); err == nil {
+		if ids, ok := invIdx.lookup(match); ok {
+			return searchByOffsets(filename, ids, match, offset, limit)
+		}
+	}
+	return searchFileLinear(filename, match, offset, limit)
+}
+
+// searchInMemory 是 IndexWatcher 激活时的内存匹配路径，Id 取自 WatcherEntry
+// 分配的稳定 id，而不是它在 snapshot 切片里的下标（下标会随 flush 压缩移动）
+func searchInMemory(entries []WatcherEntry, match SearchParams, offset, limit int64) []*SearchFileInfo {
+	rst := []*SearchFileInfo{}
+	var skipped, take int64
+
+	for _, e := range entries {
+		highlights, ok := evaluateMatch(e.Index, match)
+		if !ok {
+			continue
+		}
+
+		if skipped < offset {
+			skipped++
+			continue
+		}
+
+		rst = append(rst, &SearchFileInfo{Id: e.Id, Index: e.Index, Highlights: highlights})
+		take++
+		if limit != -1 && take >= limit {
+			break
+		}
+	}
+	return rst
+}
+
+// searchByOffsets 按候选偏移量排序后逐个 seek 读取，命中后做最终的强匹配校验
+func searchByOffsets(filename string, ids map[int64]bool, match SearchParams, offset, limit int64) ([]*SearchFileInfo, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := stream.NewJsonStream(f)
+
+	offsets := make([]int64, 0, len(ids))
+	for off := range ids {
+		offsets = append(offsets, off)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	rst := []*SearchFileInfo{}
+	var skipped, take int64
+
+	for _, off := range offsets {
+		if err := s.Offset(off); err != nil {
+			return nil, err
+		}
+
+		_, info, err := s.ScanNext(&scan.Index{})
+		if err != nil {
+			return nil, err
+		}
+
+		fi := info.(*scan.Index)
+
+		highlights, ok := evaluateMatch(fi, match)
+		if !ok {
+			continue
+		}
+
+		if skipped < offset {
+			skipped++
+			continue
+		}
+
+		rst = append(rst, &SearchFileInfo{Id: off, Index: fi, Highlights: highlights})
+		take++
+		if limit != -1 && take >= limit {
+			break
+		}
+	}
+	return rst, nil
+}
+
+// searchFileLinear 是没有可用倒排索引时的逐条扫描实现
+func searchFileLinear(filename string, match SearchParams, offset, limit int64) ([]*SearchFileInfo, error) {
 	f, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return nil, err
@@ -46,11 +156,12 @@ func SearchFile(filename string, match SearchParams, offset, limit int64) ([]*Se
 
 		fi := info.(*scan.Index)
 
-		if !isMatchSearch(fi, match) {
+		highlights, ok := evaluateMatch(fi, match)
+		if !ok {
 			continue
 		}
 
-		rst = append(rst, &SearchFileInfo{Id: offset, Index: fi})
+		rst = append(rst, &SearchFileInfo{Id: offset, Index: fi, Highlights: highlights})
 		take++
 		if limit == -1 {
 			continue
@@ -66,36 +177,483 @@ func SearchFile(filename string, match SearchParams, offset, limit int64) ([]*Se
 
 // 强匹配
 func isMatchSearch(fi *scan.Index, match SearchParams) bool {
+	_, ok := evaluateMatch(fi, match)
+	return ok
+}
+
+// evaluateMatch 在做强匹配判断的同时，为每个被查询的字段生成
+// Match 记录（matchLevel + 高亮片段），供前端直接渲染结果摘要
+func evaluateMatch(fi *scan.Index, match SearchParams) (map[string]dto.Match, bool) {
+	highlights := map[string]dto.Match{}
+	ok := true
+
 	if match.Path != "" {
-		if strings.Index(fi.Path, match.Path) == -1 {
-			return false
+		m := matchSubstring(fi.Path, match.Path)
+		highlights["path"] = m
+		if m.MatchLevel == dto.MatchLevelNone {
+			ok = false
 		}
 	}
 
 	if match.Name != "" {
-		if strings.Index(fi.Name, match.Name) == -1 {
-			return false
+		m := matchSubstring(fi.Name, match.Name)
+		highlights["name"] = m
+		if m.MatchLevel == dto.MatchLevelNone {
+			ok = false
 		}
 	}
 
 	if match.Ext != "" {
-		if fi.Ext != match.Ext {
-			return false
+		m := matchExact(fi.Ext, match.Ext)
+		highlights["ext"] = m
+		if m.MatchLevel == dto.MatchLevelNone {
+			ok = false
 		}
 	}
 
 	if match.Tag != "" {
-		mm := false
-		for _, t := range fi.Tags {
-			if t == match.Tag {
-				mm = true
-				break
-			}
+		m := matchTag(fi.Tags, match.Tag)
+		highlights["tag"] = m
+		if m.MatchLevel == dto.MatchLevelNone {
+			ok = false
 		}
-		if !mm {
-			return false
+	}
+
+	return highlights, ok
+}
+
+// matchSubstring 对 name/path 这类自由文本字段做包含匹配（与原有强匹配一致，大小写敏感）
+func matchSubstring(value, query string) dto.Match {
+	m := dto.Match{Value: value, MatchLevel: dto.MatchLevelNone}
+
+	lv := value
+	lq := query
+
+	if strings.Index(lv, lq) == -1 {
+		return m
+	}
+
+	if lv == lq {
+		m.MatchLevel = dto.MatchLevelFull
+	} else {
+		m.MatchLevel = dto.MatchLevelPartial
+	}
+
+	m.Matches = findOccurrences(lv, lq)
+	m.Highlight = highlightOccurrences(value, lv, lq)
+	return m
+}
+
+// matchExact 对 ext 这类受限取值集合的字段做精确匹配
+func matchExact(value, query string) dto.Match {
+	m := dto.Match{Value: value, MatchLevel: dto.MatchLevelNone}
+	if value != query {
+		return m
+	}
+	m.MatchLevel = dto.MatchLevelFull
+	m.Matches = []string{value}
+	m.Highlight = HighlightOpenTag + value + HighlightCloseTag
+	return m
+}
+
+// matchTag 在标签列表中查找精确命中的那一个
+func matchTag(tags []string, query string) dto.Match {
+	m := dto.Match{Value: strings.Join(tags, ","), MatchLevel: dto.MatchLevelNone}
+	for _, t := range tags {
+		if t == query {
+			m.MatchLevel = dto.MatchLevelFull
+			m.Matches = []string{t}
+			m.Highlight = HighlightOpenTag + t + HighlightCloseTag
+			return m
+		}
+	}
+	return m
+}
+
+// findOccurrences 返回 lv 中所有与 lq 逐字节相同的片段（大小写敏感，不做折叠）
+func findOccurrences(lv, lq string) []string {
+	matches := []string{}
+	start := 0
+	for {
+		i := strings.Index(lv[start:], lq)
+		if i == -1 {
+			break
+		}
+		from := start + i
+		matches = append(matches, lv[from:from+len(lq)])
+		start = from + len(lq)
+	}
+	return matches
+}
+
+// highlightOccurrences 用 HighlightOpenTag/HighlightCloseTag 包裹 value 中
+// 所有命中 query 的片段，lv/lq 用于定位偏移（matchSubstring 传入的就是
+// value/query 本身，不做大小写转换）
+func highlightOccurrences(value, lv, lq string) string {
+	var b strings.Builder
+	start := 0
+	for {
+		i := strings.Index(lv[start:], lq)
+		if i == -1 {
+			b.WriteString(value[start:])
+			break
 		}
+		from := start + i
+		b.WriteString(value[start:from])
+		b.WriteString(HighlightOpenTag)
+		b.WriteString(value[from : from+len(lq)])
+		b.WriteString(HighlightCloseTag)
+		start = from + len(lq)
 	}
+	return b.String()
+}
+
+// Predicate 是编译后的选择器条件，可以直接套用在一条索引记录上
+type Predicate interface {
+	Matches(fi *scan.Index) bool
+}
+
+type selectorOp string
+
+const (
+	selOpEquals    selectorOp = "="
+	selOpNotEquals selectorOp = "!="
+	selOpIn        selectorOp = "in"
+	selOpNotIn     selectorOp = "notin"
+	selOpRegex     selectorOp = "~="
+	selOpPrefix    selectorOp = "^="
+	selOpSuffix    selectorOp = "$="
+)
+
+// SelectorSyntaxError 描述选择器字符串在哪个位置解析失败
+type SelectorSyntaxError struct {
+	Selector string
+	Pos      int
+	Msg      string
+}
+
+func (e *SelectorSyntaxError) Error() string {
+	return fmt.Sprintf("invalid selector %q at position %d: %s", e.Selector, e.Pos, e.Msg)
+}
 
+type andPredicate struct {
+	preds []Predicate
+}
+
+func (p *andPredicate) Matches(fi *scan.Index) bool {
+	for _, pr := range p.preds {
+		if !pr.Matches(fi) {
+			return false
+		}
+	}
 	return true
 }
+
+// fieldPredicate 是单个 field <op> value(s) 条件，tag 作为多值字段单独处理
+type fieldPredicate struct {
+	field  string
+	op     selectorOp
+	values []string
+	regex  *regexp.Regexp
+}
+
+func (p *fieldPredicate) Matches(fi *scan.Index) bool {
+	if p.field == "tag" {
+		return p.matchesTags(fi.Tags)
+	}
+	return p.matchesValue(selectorFieldValue(fi, p.field))
+}
+
+func (p *fieldPredicate) matchesValue(v string) bool {
+	switch p.op {
+	case selOpEquals:
+		return v == p.values[0]
+	case selOpNotEquals:
+		return v != p.values[0]
+	case selOpPrefix:
+		return strings.HasPrefix(v, p.values[0])
+	case selOpSuffix:
+		return strings.HasSuffix(v, p.values[0])
+	case selOpRegex:
+		return p.regex.MatchString(v)
+	case selOpIn:
+		return containsString(p.values, v)
+	case selOpNotIn:
+		return !containsString(p.values, v)
+	}
+	return false
+}
+
+func (p *fieldPredicate) matchesTags(tags []string) bool {
+	switch p.op {
+	case selOpEquals:
+		return containsString(tags, p.values[0])
+	case selOpNotEquals:
+		return !containsString(tags, p.values[0])
+	case selOpIn:
+		return intersectsString(tags, p.values)
+	case selOpNotIn:
+		return !intersectsString(tags, p.values)
+	case selOpRegex:
+		for _, t := range tags {
+			if p.regex.MatchString(t) {
+				return true
+			}
+		}
+		return false
+	case selOpPrefix:
+		for _, t := range tags {
+			if strings.HasPrefix(t, p.values[0]) {
+				return true
+			}
+		}
+		return false
+	case selOpSuffix:
+		for _, t := range tags {
+			if strings.HasSuffix(t, p.values[0]) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// existsPredicate 实现 `field` / `!field` 这种存在性判断
+type existsPredicate struct {
+	field  string
+	negate bool
+}
+
+func (p *existsPredicate) Matches(fi *scan.Index) bool {
+	var present bool
+	if p.field == "tag" {
+		present = len(fi.Tags) > 0
+	} else {
+		present = selectorFieldValue(fi, p.field) != ""
+	}
+	if p.negate {
+		return !present
+	}
+	return present
+}
+
+func selectorFieldValue(fi *scan.Index, field string) string {
+	switch field {
+	case "name":
+		return fi.Name
+	case "ext":
+		return fi.Ext
+	case "path":
+		return fi.Path
+	}
+	return ""
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func intersectsString(a, b []string) bool {
+	for _, v := range a {
+		if containsString(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	selectorSetOpExpr  = regexp.MustCompile(`^([A-Za-z0-9_.]+)\s+(in|notin)\s*\((.*)\)\s*$`)
+	selectorBinOpExpr  = regexp.MustCompile(`^([A-Za-z0-9_.]+)\s*(!=|~=|\^=|\$=|=)\s*(.*)$`)
+	selectorExistsExpr = regexp.MustCompile(`^(!)?\s*([A-Za-z0-9_.]+)\s*$`)
+)
+
+// CompileSelector 把一个 k8s-style 的选择器字符串（如
+// `tag in (anime,manga),ext=mp4,name~=ep0[1-3],path!=/trash/`）
+// 编译成 Predicate，正则在解析阶段就地编译；in/notin 的取值保留为切片，
+// 每条记录匹配时逐个比较，不预先构建集合成员表
+func CompileSelector(selector string) (Predicate, error) {
+	terms := splitSelectorTerms(selector)
+
+	preds := []Predicate{}
+	pos := 0
+	for _, term := range terms {
+		trimmed := strings.TrimSpace(term)
+		if trimmed == "" {
+			pos += len(term) + 1
+			continue
+		}
+
+		p, err := parseSelectorTerm(trimmed, pos)
+		if err != nil {
+			if se, ok := err.(*SelectorSyntaxError); ok {
+				se.Selector = selector
+			}
+			return nil, err
+		}
+		preds = append(preds, p)
+		pos += len(term) + 1
+	}
+
+	if len(preds) == 0 {
+		return nil, &SelectorSyntaxError{Selector: selector, Pos: 0, Msg: "empty selector"}
+	}
+
+	return &andPredicate{preds: preds}, nil
+}
+
+// splitSelectorTerms 按顶层逗号切分选择器。圆括号内的逗号（in (a,b) 的值列表）
+// 以及方括号内的逗号（~= 正则里的字符类，如 ep0[1,3]）都不算顶层分隔符
+func splitSelectorTerms(selector string) []string {
+	terms := []string{}
+	depth := 0
+	start := 0
+	for i, r := range selector {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, selector[start:])
+	return terms
+}
+
+func parseSelectorTerm(term string, pos int) (Predicate, error) {
+	if m := selectorSetOpExpr.FindStringSubmatch(term); m != nil {
+		field := strings.ToLower(m[1])
+		values := splitSelectorValues(m[3])
+		return &fieldPredicate{field: field, op: selectorOp(m[2]), values: values}, nil
+	}
+
+	if m := selectorBinOpExpr.FindStringSubmatch(term); m != nil {
+		field := strings.ToLower(m[1])
+		op := selectorOp(m[2])
+		value := strings.TrimSpace(m[3])
+
+		fp := &fieldPredicate{field: field, op: op, values: []string{value}}
+		if op == selOpRegex {
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, &SelectorSyntaxError{Pos: pos, Msg: "invalid regexp: " + err.Error()}
+			}
+			fp.regex = re
+		}
+		return fp, nil
+	}
+
+	if m := selectorExistsExpr.FindStringSubmatch(term); m != nil {
+		return &existsPredicate{field: strings.ToLower(m[2]), negate: m[1] == "!"}, nil
+	}
+
+	return nil, &SelectorSyntaxError{Pos: pos, Msg: "unrecognized selector term: " + term}
+}
+
+func splitSelectorValues(s string) []string {
+	parts := strings.Split(s, ",")
+	values := make([]string, 0, len(parts))
+	for _, p := range parts {
+		v := strings.TrimSpace(p)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// SearchFileWithSelector 与 SearchFile 类似，但接受一个 selector 表达式而不是
+// 固定的 AND 字段集合，适合需要 in/regex/前后缀 这类更灵活查询的场景。
+// 和 SearchFile 一样优先走 IndexWatcher 的内存缓存：selector 的 regex/prefix/
+// suffix 这类条件没法用 exact-match/trigram 倒排索引去narrow候选集，所以这里
+// 没有 searchByOffsets 那一档，但至少不能在 watcher 已经接管之后还去读一份
+// 可能滞后 flushPeriod、甚至还没落盘过的 index.json
+func SearchFileWithSelector(filename, selector string, offset, limit int64) ([]*SearchFileInfo, error) {
+	pred, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if w := getActiveWatcher(); w != nil {
+		return selectorSearchInMemory(w.Snapshot(), pred, offset, limit), nil
+	}
+
+	return selectorSearchFileLinear(filename, pred, offset, limit)
+}
+
+// selectorSearchInMemory 是 IndexWatcher 激活时 SearchFileWithSelector 的内存匹配路径
+func selectorSearchInMemory(entries []WatcherEntry, pred Predicate, offset, limit int64) []*SearchFileInfo {
+	rst := []*SearchFileInfo{}
+	var skipped, take int64
+
+	for _, e := range entries {
+		if !pred.Matches(e.Index) {
+			continue
+		}
+
+		if skipped < offset {
+			skipped++
+			continue
+		}
+
+		rst = append(rst, &SearchFileInfo{Id: e.Id, Index: e.Index})
+		take++
+		if limit != -1 && take >= limit {
+			break
+		}
+	}
+	return rst
+}
+
+// selectorSearchFileLinear 是没有 IndexWatcher 时 SearchFileWithSelector 的逐条扫描实现
+func selectorSearchFileLinear(filename string, pred Predicate, offset, limit int64) ([]*SearchFileInfo, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := stream.NewJsonStream(f)
+
+	rst := []*SearchFileInfo{}
+	var skipped, take int64
+
+	for {
+		off, info, err := s.ScanNext(&scan.Index{})
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		fi := info.(*scan.Index)
+
+		if !pred.Matches(fi) {
+			continue
+		}
+
+		if skipped < offset {
+			skipped++
+			continue
+		}
+
+		rst = append(rst, &SearchFileInfo{Id: off, Index: fi})
+		take++
+		if limit != -1 && take >= limit {
+			break
+		}
+	}
+	return rst, nil
+}
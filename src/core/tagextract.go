@@ -0,0 +1,207 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/rwcarlsen/goexif/exif"
+	"gopkg.in/yaml.v3"
+)
+
+// TagExtractor 从一个文件里提取标签。content 已经定位到文件开头；
+// 不需要读取内容的实现可以直接忽略这个参数
+type TagExtractor interface {
+	Extract(path string, info fs.FileInfo, content io.Reader) ([]string, error)
+}
+
+// tagExtractorRegistry 按扩展名保存一组按配置顺序执行的 TagExtractor
+type tagExtractorRegistry struct {
+	byExt map[string][]TagExtractor
+}
+
+func newTagExtractorRegistry() *tagExtractorRegistry {
+	return &tagExtractorRegistry{byExt: map[string][]TagExtractor{}}
+}
+
+func (r *tagExtractorRegistry) register(ext string, ex TagExtractor) {
+	r.byExt[ext] = append(r.byExt[ext], ex)
+}
+
+// extract 依次跑完某个扩展名注册的所有提取器并合并结果，
+// 单个提取器失败不影响其余提取器继续执行
+func (r *tagExtractorRegistry) extract(path string, info fs.FileInfo) ([]string, error) {
+	extractors := r.byExt[getExt(info.Name())]
+	if len(extractors) == 0 {
+		return nil, nil
+	}
+
+	tags := []string{}
+	for _, ex := range extractors {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		t, err := ex.Extract(path, info, f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		tags = append(tags, t...)
+	}
+	return tags, nil
+}
+
+// buildTagExtractorRegistry 按 ScanConfig.Extractors（ext -> 提取器名顺序）
+// 组装注册表，内置的提取器名为 filename/exif/id3/sidecar
+func buildTagExtractorRegistry(cfg *ScanConfig, filenameExpr *regexp.Regexp) *tagExtractorRegistry {
+	reg := newTagExtractorRegistry()
+
+	builtin := map[string]TagExtractor{
+		"filename": FilenameTagExtractor{Expr: filenameExpr},
+		"exif":     ExifTagExtractor{},
+		"id3":      Id3TagExtractor{},
+		"sidecar":  SidecarTagExtractor{},
+	}
+
+	for ext, names := range cfg.Extractors {
+		for _, name := range names {
+			if ex, ok := builtin[name]; ok {
+				reg.register(ext, ex)
+			}
+		}
+	}
+
+	return reg
+}
+
+// mergeTags 按顺序合并多组标签并去重
+func mergeTags(lists ...[]string) []string {
+	seen := map[string]bool{}
+	rst := []string{}
+	for _, list := range lists {
+		for _, t := range list {
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			rst = append(rst, t)
+		}
+	}
+	return rst
+}
+
+// FilenameTagExtractor 包装现有的文件名正则提取逻辑，使其可以注册进 registry
+type FilenameTagExtractor struct {
+	Expr *regexp.Regexp
+}
+
+func (e FilenameTagExtractor) Extract(path string, info fs.FileInfo, content io.Reader) ([]string, error) {
+	return parseTag(info.Name(), e.Expr)
+}
+
+// ExifTagExtractor 从图片的 EXIF 元数据里提取标签（相机厂商/型号）
+type ExifTagExtractor struct{}
+
+func (ExifTagExtractor) Extract(path string, info fs.FileInfo, content io.Reader) ([]string, error) {
+	x, err := exif.Decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	if v, err := x.Get(exif.Make); err == nil {
+		if s, err := v.StringVal(); err == nil && s != "" {
+			tags = append(tags, strings.TrimSpace(s))
+		}
+	}
+	if v, err := x.Get(exif.Model); err == nil {
+		if s, err := v.StringVal(); err == nil && s != "" {
+			tags = append(tags, strings.TrimSpace(s))
+		}
+	}
+	return tags, nil
+}
+
+// Id3TagExtractor 从音频文件的 ID3 标签里提取流派与艺术家
+type Id3TagExtractor struct{}
+
+func (Id3TagExtractor) Extract(path string, info fs.FileInfo, content io.Reader) ([]string, error) {
+	m, err := tag.ReadFrom(content)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	if g := m.Genre(); g != "" {
+		tags = append(tags, g)
+	}
+	if a := m.Artist(); a != "" {
+		tags = append(tags, a)
+	}
+	return tags, nil
+}
+
+// SidecarTagExtractor 读取 `<file>.tags`（每行一个标签），
+// 不存在的话退化到读取文件内容里的 YAML front-matter 的 tags 字段
+type SidecarTagExtractor struct{}
+
+func (SidecarTagExtractor) Extract(path string, info fs.FileInfo, content io.Reader) ([]string, error) {
+	if tags, err := readSidecarFile(path + ".tags"); err == nil {
+		return tags, nil
+	}
+	return readFrontMatterTags(content)
+}
+
+func readSidecarFile(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			tags = append(tags, line)
+		}
+	}
+	return tags, nil
+}
+
+func readFrontMatterTags(content io.Reader) ([]string, error) {
+	r := bufio.NewReader(content)
+
+	first, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if strings.TrimSpace(first) != "---" {
+		return nil, nil
+	}
+
+	var block strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		block.WriteString(line)
+		if err != nil {
+			break
+		}
+	}
+
+	var fm struct {
+		Tags []string `yaml:"tags"`
+	}
+	if err := yaml.Unmarshal([]byte(block.String()), &fm); err != nil {
+		return nil, err
+	}
+	return fm.Tags, nil
+}
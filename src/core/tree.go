@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"dxkite.cn/explore-me/src/core/scan"
+	"dxkite.cn/explore-me/src/core/stream"
+)
+
+// BuildTree 把一组索引记录按 Path 折叠成嵌套的目录树：目录节点是
+// map[string]interface{}，文件叶子节点是对应的 *scan.Index，
+// 供客户端一次性拿到整棵层级结构做离线浏览或本地过滤
+func BuildTree(indices []*scan.Index) map[string]interface{} {
+	root := map[string]interface{}{}
+
+	for _, idx := range indices {
+		parts := strings.Split(strings.Trim(idx.Path, "/"), "/")
+		dir := root
+
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+
+			if i == len(parts)-1 {
+				dir[part] = idx
+				continue
+			}
+
+			next, ok := dir[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				dir[part] = next
+			}
+			dir = next
+		}
+	}
+
+	return root
+}
+
+// WriteTreeZstd 把 tree 编码为 JSON 后经 zstd 压缩写入 w，
+// 用于 /api/tree 这类一次性下发整棵目录树的场景。
+// enc.Close() 才是真正把最后一帧 flush 给 w 的地方，它的错误不能用 defer
+// 悄悄丢掉——否则调用方看到 nil 错误，以为整段数据都写完整了
+func WriteTreeZstd(w io.Writer, tree interface{}) error {
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(enc).Encode(tree); err != nil {
+		enc.Close()
+		return err
+	}
+
+	return enc.Close()
+}
+
+// LoadAllIndices 读取 index.json 中的全部记录，是 BuildTree 的常见数据来源
+func LoadAllIndices(filename string) ([]*scan.Index, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := stream.NewJsonStream(f)
+	rst := []*scan.Index{}
+
+	for {
+		_, info, err := s.ScanNext(&scan.Index{})
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		rst = append(rst, info.(*scan.Index))
+	}
+	return rst, nil
+}
@@ -0,0 +1,415 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"dxkite.cn/explore-me/src/core/scan"
+	"dxkite.cn/explore-me/src/core/stream"
+)
+
+// IndexWatcher 维护一份常驻内存的 []*scan.Index 缓存：启动时从 index.json 预热，
+// 之后通过递归 fsnotify 订阅对单条记录做增量修补，并定期把压缩后的结果刷回磁盘。
+// 这是为了替代 IndexCreator.Create 依赖根目录 mtime 的全量重建——根目录的 mtime
+// 不会因为深层子目录的写入而更新，全量重建既昂贵又会漏掉这类变更。
+type IndexWatcher struct {
+	mu      sync.RWMutex
+	entries []*scan.Index
+	tomb    []bool
+	ids     []int64 // 与 entries 一一对应的稳定 Id，见 WatcherEntry 的说明
+	byPath  map[string]int
+
+	root          string
+	dataRoot      string
+	config        *ScanConfig
+	tagExpr       *regexp.Regexp
+	ignoreNameMap map[string]bool
+	ignoreExtMap  map[string]bool
+
+	watcher     *fsnotify.Watcher
+	flushPeriod time.Duration
+	done        chan struct{}
+
+	nextID int64 // 下一个待分配的 Id，新建记录时使用，seed 阶段据已有偏移量推进
+
+	// pendingRemoved 记录最近一次 flush 周期内被删除的条目，key 是文件名，
+	// value 是它在 entries 中的槽位，重命名产生的 Create 事件如果命中同名文件，
+	// 会复用这个槽位而不是追加新记录，从而保留原始 Id
+	pendingRemoved map[string]int
+}
+
+// WatcherEntry 是 IndexWatcher 内存缓存里的一条记录及其稳定 Id。
+// 这个 Id 在记录首次加入缓存时分配；重命名命中同一文件名时会复用同一个
+// 槽位并保留原 Id；flush 做压缩、记录在切片里的位置发生变化时 Id 也不变。
+type WatcherEntry struct {
+	Id    int64
+	Index *scan.Index
+}
+
+// NewIndexWatcher 创建一个 IndexWatcher，从已有的 index.json 预热内存缓存，
+// 并递归订阅 root 下所有目录的 fsnotify 事件
+func NewIndexWatcher(cfg *ScanConfig, root, dataRoot string) (*IndexWatcher, error) {
+	reg, err := regexp.Compile(cfg.TagExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreNameMap := map[string]bool{}
+	for _, v := range cfg.IgnoreName {
+		ignoreNameMap[v] = true
+	}
+
+	ignoreExtMap := map[string]bool{}
+	for _, v := range cfg.IgnoreExt {
+		ignoreExtMap[v] = true
+	}
+
+	w := &IndexWatcher{
+		config:         cfg,
+		root:           root,
+		dataRoot:       dataRoot,
+		tagExpr:        reg,
+		ignoreNameMap:  ignoreNameMap,
+		ignoreExtMap:   ignoreExtMap,
+		byPath:         map[string]int{},
+		pendingRemoved: map[string]int{},
+		flushPeriod:    10 * time.Second,
+		done:           make(chan struct{}),
+	}
+
+	if err := w.seed(path.Join(dataRoot, cfg.IndexFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w.watcher = fw
+
+	if err := filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if w.ignoreNameMap[info.Name()] {
+				return filepath.SkipDir
+			}
+			return fw.Add(p)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *IndexWatcher) seed(indexFile string) error {
+	f, err := os.OpenFile(indexFile, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := stream.NewJsonStream(f)
+	for {
+		cur, info, err := s.ScanNext(&scan.Index{})
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		fi := info.(*scan.Index)
+		w.entries = append(w.entries, fi)
+		w.tomb = append(w.tomb, false)
+		w.ids = append(w.ids, cur)
+		w.byPath[fi.Path] = len(w.entries) - 1
+		if cur >= w.nextID {
+			w.nextID = cur + 1
+		}
+	}
+	return nil
+}
+
+// Start 启动事件循环，非阻塞
+func (w *IndexWatcher) Start() error {
+	go w.loop()
+	return nil
+}
+
+// Stop 停止事件循环并释放底层的 fsnotify.Watcher
+func (w *IndexWatcher) Stop() error {
+	close(w.done)
+	return w.watcher.Close()
+}
+
+func (w *IndexWatcher) loop() {
+	ticker := time.NewTicker(w.flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(ev)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("index watcher error:", err)
+		case <-ticker.C:
+			w.sweepPendingRemoved()
+			if err := w.flush(); err != nil {
+				log.Println("index watcher flush error:", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *IndexWatcher) handleEvent(ev fsnotify.Event) {
+	switch {
+	case ev.Op&fsnotify.Create != 0:
+		w.handleCreate(ev.Name)
+	case ev.Op&fsnotify.Remove != 0:
+		w.handleRemove(ev.Name)
+	case ev.Op&fsnotify.Rename != 0:
+		// 重命名后的新路径会随之而来的 Create 事件补上，这里先按删除处理
+		w.handleRemove(ev.Name)
+	case ev.Op&fsnotify.Chmod != 0:
+		// 不记录权限位，chmod 不需要修补缓存
+	}
+}
+
+func (w *IndexWatcher) handleCreate(p string) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+
+	name := info.Name()
+
+	// 与 IndexCreator.createIndexFile 保持一致：被忽略的名字整体跳过
+	// （目录还要停止继续订阅它的子树），被忽略的扩展名只跳过文件本身
+	if w.ignoreNameMap[name] {
+		return
+	}
+
+	if info.IsDir() {
+		_ = w.watcher.Add(p)
+		// 整个子树是一次性搬进来的（比如 mv/解压），这个目录本身的 Create
+		// 事件不会带来它已有子项的事件，得自己递归走一遍补上，否则这些文件
+		// 要等到被单独碰一下才会进缓存——这正是引入 fsnotify 想要消灭的盲区
+		w.walkNew(p)
+		return
+	}
+
+	w.addFile(p, info)
+}
+
+// walkNew 递归处理一个刚出现的目录下已有的所有子项，逻辑与 NewIndexWatcher
+// 里预热阶段的 filepath.Walk 一致：目录同样要跳过忽略名单并继续订阅
+func (w *IndexWatcher) walkNew(root string) {
+	_ = filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		if info.IsDir() {
+			if w.ignoreNameMap[info.Name()] {
+				return filepath.SkipDir
+			}
+			_ = w.watcher.Add(p)
+			return nil
+		}
+		w.addFile(p, info)
+		return nil
+	})
+}
+
+// addFile 把一个具体文件登记进内存缓存，命中 pendingRemoved 槽位时复用原 Id
+func (w *IndexWatcher) addFile(p string, info fs.FileInfo) {
+	name := info.Name()
+
+	if w.ignoreNameMap[name] {
+		return
+	}
+
+	ext := getExt(name)
+	if w.ignoreExtMap[ext] {
+		return
+	}
+
+	relPath := w.relPath(p)
+
+	tags, _ := parseTag(name, w.tagExpr)
+
+	entry := &scan.Index{Name: name, Path: relPath, Ext: ext, Tags: tags}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if idx, ok := w.pendingRemoved[name]; ok {
+		delete(w.pendingRemoved, name)
+		w.entries[idx] = entry
+		w.tomb[idx] = false
+		w.byPath[relPath] = idx
+		return
+	}
+
+	id := w.nextID
+	w.nextID++
+
+	w.entries = append(w.entries, entry)
+	w.tomb = append(w.tomb, false)
+	w.ids = append(w.ids, id)
+	w.byPath[relPath] = len(w.entries) - 1
+}
+
+func (w *IndexWatcher) handleRemove(p string) {
+	relPath := w.relPath(p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	idx, ok := w.byPath[relPath]
+	if !ok {
+		return
+	}
+	delete(w.byPath, relPath)
+	w.tomb[idx] = true
+	w.pendingRemoved[path.Base(relPath)] = idx
+}
+
+// sweepPendingRemoved 清空重命名宽限期内未被复用的槽位记录，
+// 它们已经在 handleRemove 里被打上墓碑，会在下一次 flush 时被压缩掉
+func (w *IndexWatcher) sweepPendingRemoved() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pendingRemoved = map[string]int{}
+}
+
+func (w *IndexWatcher) relPath(p string) string {
+	absRoot, _ := filepath.Abs(w.root)
+	absP, _ := filepath.Abs(p)
+	return normalizePath(strings.TrimPrefix(absP, absRoot))
+}
+
+// Snapshot 返回当前内存缓存的一份浅拷贝（已剔除墓碑项），
+// 是 SearchFile 的内存快速路径使用的数据源
+func (w *IndexWatcher) Snapshot() []WatcherEntry {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	rst := make([]WatcherEntry, 0, len(w.entries))
+	for i, e := range w.entries {
+		if w.tomb[i] {
+			continue
+		}
+		rst = append(rst, WatcherEntry{Id: w.ids[i], Index: e})
+	}
+	return rst
+}
+
+// flush 把压缩后（去掉墓碑项）的缓存原子性地写回 index.json，并重建配套的倒排索引
+func (w *IndexWatcher) flush() error {
+	w.mu.Lock()
+	entries := make([]*scan.Index, 0, len(w.entries))
+	tomb := make([]bool, 0, len(w.entries))
+	ids := make([]int64, 0, len(w.entries))
+	byPath := map[string]int{}
+	for i, e := range w.entries {
+		if w.tomb[i] {
+			continue
+		}
+		entries = append(entries, e)
+		tomb = append(tomb, false)
+		ids = append(ids, w.ids[i])
+		byPath[e.Path] = len(entries) - 1
+	}
+	w.entries = entries
+	w.tomb = tomb
+	w.ids = ids
+	w.byPath = byPath
+	w.mu.Unlock()
+
+	indexFile := path.Join(w.dataRoot, w.config.IndexFile)
+	tmp := indexFile + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	invIdx := newInvertedIndex()
+	var offset int64
+
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		n, err := f.Write(b)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		invIdx.add(offset, e.Name, e.Path, e.Ext, e.Tags)
+		offset += int64(n)
+
+		n, err = f.Write([]byte{'\n'})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		offset += int64(n)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, indexFile); err != nil {
+		return err
+	}
+
+	return writeJsonFile(indexFile+".inv", invIdx)
+}
+
+var (
+	activeWatcherMu sync.RWMutex
+	activeWatcher   *IndexWatcher
+)
+
+// SetActiveWatcher 注册（或用 nil 注销）全局的 IndexWatcher，
+// SearchFile 在它启用时直接复用内存缓存，完全跳过 index.json 的文件 I/O
+func SetActiveWatcher(w *IndexWatcher) {
+	activeWatcherMu.Lock()
+	defer activeWatcherMu.Unlock()
+	activeWatcher = w
+}
+
+func getActiveWatcher() *IndexWatcher {
+	activeWatcherMu.RLock()
+	defer activeWatcherMu.RUnlock()
+	return activeWatcher
+}